@@ -1,6 +1,7 @@
 package tarexport
 
 import (
+	"archive/tar"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,6 +10,7 @@ import (
 	"path/filepath"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/docker/distribution"
 	"github.com/docker/docker/image"
 	"github.com/docker/docker/image/v1"
 	"github.com/docker/docker/layer"
@@ -23,7 +25,104 @@ import (
 	ociv1 "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
-func (l *tarexporter) Load(inTar io.ReadCloser, name string, refs map[string]string, outStream io.Writer) error {
+// nondistributableMediaType reports whether mt identifies an OCI or docker
+// v2.2 foreign layer: one whose descriptor names external URLs it is
+// distributed from. Such a layer is still registered from the blob in the
+// archive; its descriptor is recorded with it so the URLs are kept.
+func nondistributableMediaType(mt string) bool {
+	switch mt {
+	case ociv1.MediaTypeImageLayerNonDistributable,
+		ociv1.MediaTypeImageLayerNonDistributableGzip,
+		schema2ForeignLayerMediaType, schema2ForeignLayerMediaTypeGzip:
+		return true
+	}
+	return false
+}
+
+const (
+	schema2ForeignLayerMediaType     = "application/vnd.docker.image.rootfs.foreign.diff.tar"
+	schema2ForeignLayerMediaTypeGzip = "application/vnd.docker.image.rootfs.foreign.diff.tar.gzip"
+)
+
+// LoadOptions carries optional, format-specific behavior for Load. The zero
+// value preserves the historical behavior of loading every manifest.
+type LoadOptions struct {
+	// Platform restricts loading of a multi-platform OCI image index to the
+	// manifests matching it; an empty Variant matches any variant. If nil,
+	// every manifest in the index is loaded.
+	Platform *ociv1.Platform
+
+	// Verifier checks an image's signatures/attestations before any of its
+	// layers are registered. If nil, no verification is performed. Images
+	// listed in a manifest.json without RepoTags are not passed to it.
+	Verifier Verifier
+}
+
+// Signature is a detached signature or attestation found alongside an image
+// manifest, such as a cosign simple-signing blob or an in-toto attestation
+// referenced from an OCI index.
+type Signature struct {
+	MediaType   string
+	Digest      digest.Digest
+	Annotations map[string]string
+}
+
+// Verifier checks that an image manifest's signatures/attestations satisfy
+// policy before Load registers any of its content. Returning an error
+// aborts the load of that manifest with no side effects on the image or
+// layer stores.
+type Verifier interface {
+	VerifyManifest(ref reference.Named, manifestDigest digest.Digest, sigs []Signature) error
+}
+
+// noopVerifier is the default Verifier: it accepts everything, preserving
+// the pre-verification behavior of Load.
+type noopVerifier struct{}
+
+func (noopVerifier) VerifyManifest(reference.Named, digest.Digest, []Signature) error {
+	return nil
+}
+
+const (
+	mediaTypeCosignSimpleSigning = "application/vnd.dev.cosign.simplesigning.v1+json"
+	mediaTypeInTotoAttestation   = "application/vnd.in-toto+json"
+)
+
+func isSignatureMediaType(mt string) bool {
+	switch mt {
+	case mediaTypeCosignSimpleSigning, mediaTypeInTotoAttestation:
+		return true
+	}
+	return false
+}
+
+// collectManifestSignatures finds every signature/attestation descriptor in
+// an OCI index and groups them by the manifest digest they apply to, as
+// recorded in their "vnd.docker.reference.digest" annotation.
+func collectManifestSignatures(index ociv1.ImageIndex) map[digest.Digest][]Signature {
+	sigs := make(map[digest.Digest][]Signature)
+	for _, md := range index.Manifests {
+		if !isSignatureMediaType(md.MediaType) {
+			continue
+		}
+		target, ok := md.Annotations["vnd.docker.reference.digest"]
+		if !ok {
+			continue
+		}
+		sigs[digest.Digest(target)] = append(sigs[digest.Digest(target)], Signature{
+			MediaType:   md.MediaType,
+			Digest:      digest.Digest(md.Digest),
+			Annotations: md.Annotations,
+		})
+	}
+	return sigs
+}
+
+func (l *tarexporter) Load(inTar io.ReadCloser, name string, refs map[string]string, outStream io.Writer, opts LoadOptions) error {
+	if opts.Verifier == nil {
+		opts.Verifier = noopVerifier{}
+	}
+
 	// add progress for load image
 	var (
 		sf             = streamformatter.NewJSONStreamFormatter()
@@ -50,7 +149,7 @@ func (l *tarexporter) Load(inTar io.ReadCloser, name string, refs map[string]str
 	ociLayoutFile, err := os.Open(ociLayoutPath)
 	if err == nil {
 		ociLayoutFile.Close()
-		return l.ociLoad(tmpDir, name, refs, outStream, progressOutput)
+		return l.ociLoad(tmpDir, name, refs, outStream, progressOutput, opts)
 	}
 
 	// read manifest, if no file then load in legacy mode
@@ -72,16 +171,150 @@ func (l *tarexporter) Load(inTar io.ReadCloser, name string, refs map[string]str
 		return err
 	}
 
-	return l.loadHelper(tmpDir, manifest, outStream, progressOutput)
+	return l.loadHelper(tmpDir, manifest, outStream, progressOutput, opts.Verifier, collectDirectorySignatures(tmpDir))
 }
 
-func (l *tarexporter) loadHelper(tmpDir string, manifests []manifestItem, outStream io.Writer, progressOutput progress.Output) error {
-	for _, m := range manifests {
-		configPath, err := safePath(tmpDir, m.Config)
+// collectDirectorySignatures reads a docker-archive's optional signatures/
+// directory. docker-archive carries no per-manifest digest to key
+// signatures by, so every file found is returned unbound to any image;
+// loadHelper passes them to the verifier only when the archive holds a
+// single manifest.
+func collectDirectorySignatures(tmpDir string) []Signature {
+	sigDir, err := safePath(tmpDir, "signatures")
+	if err != nil {
+		return nil
+	}
+	fis, err := ioutil.ReadDir(sigDir)
+	if err != nil {
+		return nil
+	}
+	sigs := make([]Signature, 0, len(fis))
+	for _, fi := range fis {
+		sigs = append(sigs, Signature{Annotations: map[string]string{"filename": fi.Name()}})
+	}
+	return sigs
+}
+
+// tarEntry records where an entry's content begins within a seekable tar
+// stream, so it can be re-read on demand without buffering the whole tar.
+type tarEntry struct {
+	header *tar.Header
+	offset int64
+}
+
+// indexTarEntries makes a single sequential pass over tarFile, recording the
+// content offset of every entry by name. tarFile is left positioned at EOF;
+// callers re-seek before reading any entry's content.
+func indexTarEntries(tarFile *os.File) (map[string]tarEntry, error) {
+	if _, err := tarFile.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(tarFile)
+	entries := make(map[string]tarEntry)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
+			return nil, err
+		}
+		offset, err := tarFile.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		entries[hdr.Name] = tarEntry{header: hdr, offset: offset}
+	}
+	return entries, nil
+}
+
+// LoadStream loads a manifest.json-style image archive directly out of
+// tarFile, piping each layer's compressed bytes straight into the layer
+// store instead of first extracting the whole tar to a temp directory. This
+// only helps when tarFile is seekable (a real file, not a pipe): a first
+// pass indexes every entry's offset, then a second pass reads manifest.json,
+// config and layer blobs back out by seeking, in manifest order, since a
+// layer's chain ID requires its parent to already be registered regardless
+// of the order entries happen to appear in the tar. OCI layouts and legacy
+// (pre-1.10) archives, and any non-seekable input, fall back to Load.
+func (l *tarexporter) LoadStream(tarFile *os.File, name string, refs map[string]string, outStream io.Writer, opts LoadOptions) error {
+	if opts.Verifier == nil {
+		opts.Verifier = noopVerifier{}
+	}
+
+	// Probe seekability before indexing: a non-seekable input (e.g. the
+	// stdin pipe from `docker load <image.tar`) fails this exact Seek, and
+	// indexTarEntries would only fail the same way, so it can't be used to
+	// tell "not seekable" apart from "corrupt tar" after the fact.
+	if _, err := tarFile.Seek(0, io.SeekStart); err != nil {
+		return l.Load(tarFile, name, refs, outStream, opts)
+	}
+
+	entries, err := indexTarEntries(tarFile)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := entries["oci-layout"]; ok {
+		if _, err := tarFile.Seek(0, io.SeekStart); err != nil {
 			return err
 		}
-		config, err := ioutil.ReadFile(configPath)
+		return l.Load(tarFile, name, refs, outStream, opts)
+	}
+
+	manifestEntry, ok := entries[manifestFileName]
+	if !ok {
+		if _, err := tarFile.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		return l.Load(tarFile, name, refs, outStream, opts)
+	}
+
+	sf := streamformatter.NewJSONStreamFormatter()
+	progressOutput := sf.NewProgressOutput(outStream, false)
+
+	var manifest []manifestItem
+	manifestReader := io.NewSectionReader(tarFile, manifestEntry.offset, manifestEntry.header.Size)
+	if err := json.NewDecoder(manifestReader).Decode(&manifest); err != nil {
+		return err
+	}
+
+	return l.loadHelperStream(tarFile, entries, manifest, outStream, progressOutput, opts.Verifier)
+}
+
+// collectStreamSignatures is the streaming counterpart of
+// collectDirectorySignatures: it reads the same optional signatures/
+// directory out of an indexed tar instead of an extracted one.
+func collectStreamSignatures(entries map[string]tarEntry) []Signature {
+	var sigs []Signature
+	for name := range entries {
+		if filepath.Dir(name) != "signatures" || filepath.Clean(name) == "signatures" {
+			continue
+		}
+		sigs = append(sigs, Signature{Annotations: map[string]string{"filename": filepath.Base(name)}})
+	}
+	return sigs
+}
+
+// loadHelperStream is the streaming counterpart of loadHelper: it reads
+// config and layer blobs out of tarFile by seeking to their indexed offset
+// instead of from files already extracted to a temp directory.
+func (l *tarexporter) loadHelperStream(tarFile *os.File, entries map[string]tarEntry, manifests []manifestItem, outStream io.Writer, progressOutput progress.Output, verifier Verifier) error {
+	sigs := collectStreamSignatures(entries)
+	if len(manifests) != 1 {
+		// A docker-archive signatures/ directory carries no per-manifest
+		// digest to bind a signature to a specific image, so with more than
+		// one manifest in the tar there's no way to tell which image a
+		// signature actually covers. Don't let an unrelated image's
+		// signature vouch for this one.
+		sigs = nil
+	}
+	for _, m := range manifests {
+		configEntry, ok := entries[m.Config]
+		if !ok {
+			return fmt.Errorf("invalid manifest, %s not found in archive", m.Config)
+		}
+		config, err := ioutil.ReadAll(io.NewSectionReader(tarFile, configEntry.offset, configEntry.header.Size))
 		if err != nil {
 			return err
 		}
@@ -97,16 +330,27 @@ func (l *tarexporter) loadHelper(tmpDir string, manifests []manifestItem, outStr
 			return fmt.Errorf("invalid manifest, layers length mismatch: expected %q, got %q", expected, actual)
 		}
 
-		for i, diffID := range img.RootFS.DiffIDs {
-			layerPath, err := safePath(tmpDir, m.Layers[i])
+		if len(m.RepoTags) > 0 {
+			ref, err := reference.ParseNamed(m.RepoTags[0])
 			if err != nil {
 				return err
 			}
+			if err := verifier.VerifyManifest(ref, "", sigs); err != nil {
+				return fmt.Errorf("signature verification failed for %s: %v", m.RepoTags[0], err)
+			}
+		}
+
+		for i, diffID := range img.RootFS.DiffIDs {
+			layerEntry, ok := entries[m.Layers[i]]
+			if !ok {
+				return fmt.Errorf("invalid manifest, %s not found in archive", m.Layers[i])
+			}
 			r := rootFS
 			r.Append(diffID)
 			newLayer, err := l.ls.Get(r.ChainID())
 			if err != nil {
-				newLayer, err = l.loadLayer(layerPath, rootFS, diffID.String(), progressOutput)
+				sr := io.NewSectionReader(tarFile, layerEntry.offset, layerEntry.header.Size)
+				newLayer, err = l.loadLayerStream(sr, layerEntry.header.Size, rootFS, diffID.String(), m.LayerSources[diffID], progressOutput)
 				if err != nil {
 					return err
 				}
@@ -118,54 +362,155 @@ func (l *tarexporter) loadHelper(tmpDir string, manifests []manifestItem, outStr
 			rootFS.Append(diffID)
 		}
 
-		imgID, err := l.is.Create(config)
+		if err := l.registerImage(config, m.RepoTags, outStream); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loadLayerStream is the streaming counterpart of loadLayer: it decompresses
+// and registers r directly, without ever landing the compressed blob on
+// disk.
+func (l *tarexporter) loadLayerStream(r io.Reader, size int64, rootFS image.RootFS, id string, foreignSrc distribution.Descriptor, progressOutput progress.Output) (layer.Layer, error) {
+	if progressOutput != nil {
+		r = progress.NewProgressReader(r, progressOutput, size, stringid.TruncateID(id), "Loading layer")
+	}
+
+	inflatedLayerData, err := archive.DecompressStream(r)
+	if err != nil {
+		return nil, err
+	}
+	defer inflatedLayerData.Close()
+
+	if ds, ok := l.ls.(layer.DescribableStore); ok && foreignSrc.Digest != "" {
+		return ds.RegisterWithDescriptor(inflatedLayerData, rootFS.ChainID(), foreignSrc)
+	}
+	return l.ls.Register(inflatedLayerData, rootFS.ChainID())
+}
+
+func (l *tarexporter) loadHelper(tmpDir string, manifests []manifestItem, outStream io.Writer, progressOutput progress.Output, verifier Verifier, sigs []Signature) error {
+	if len(manifests) != 1 {
+		// See the identical guard in loadHelperStream: directory-sourced
+		// signatures aren't keyed by digest, so they can only be trusted to
+		// cover the one image in an archive that has just one.
+		sigs = nil
+	}
+	for _, m := range manifests {
+		configPath, err := safePath(tmpDir, m.Config)
+		if err != nil {
+			return err
+		}
+		config, err := ioutil.ReadFile(configPath)
 		if err != nil {
 			return err
 		}
+		img, err := image.NewFromJSON(config)
+		if err != nil {
+			return err
+		}
+		var rootFS image.RootFS
+		rootFS = *img.RootFS
+		rootFS.DiffIDs = nil
+
+		if expected, actual := len(m.Layers), len(img.RootFS.DiffIDs); expected != actual {
+			return fmt.Errorf("invalid manifest, layers length mismatch: expected %q, got %q", expected, actual)
+		}
 
-		for _, repoTag := range m.RepoTags {
-			named, err := reference.ParseNamed(repoTag)
+		if len(m.RepoTags) > 0 {
+			ref, err := reference.ParseNamed(m.RepoTags[0])
 			if err != nil {
 				return err
 			}
-			ref, ok := named.(reference.NamedTagged)
-			if !ok {
-				return fmt.Errorf("invalid tag %q", repoTag)
+			if err := verifier.VerifyManifest(ref, "", sigs); err != nil {
+				return fmt.Errorf("signature verification failed for %s: %v", m.RepoTags[0], err)
 			}
-			l.setLoadedTag(ref, imgID, outStream)
-			logrus.Debugf("Load() - %v(%v) has been loaded.", ref, imgID)
-			sf := streamformatter.NewJSONStreamFormatter()
-			outStream.Write(sf.FormatStatus("", "%v(%v) has been loaded.", ref, imgID))
+		}
+
+		for i, diffID := range img.RootFS.DiffIDs {
+			layerPath, err := safePath(tmpDir, m.Layers[i])
+			if err != nil {
+				return err
+			}
+			r := rootFS
+			r.Append(diffID)
+			newLayer, err := l.ls.Get(r.ChainID())
+			if err != nil {
+				newLayer, err = l.loadLayer(layerPath, rootFS, diffID.String(), m.LayerSources[diffID], progressOutput)
+				if err != nil {
+					return err
+				}
+			}
+			defer layer.ReleaseAndLog(l.ls, newLayer)
+			if expected, actual := diffID, newLayer.DiffID(); expected != actual {
+				return fmt.Errorf("invalid diffID for layer %d: expected %q, got %q", i, expected, actual)
+			}
+			rootFS.Append(diffID)
+		}
+
+		if err := l.registerImage(config, m.RepoTags, outStream); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
-func (l *tarexporter) loadLayer(filename string, rootFS image.RootFS, id string, progressOutput progress.Output) (layer.Layer, error) {
-	rawTar, err := os.Open(filename)
+// registerImage creates an image from config and applies repoTags to it,
+// renaming any tag that already points at a different image.
+func (l *tarexporter) registerImage(config []byte, repoTags []string, outStream io.Writer) error {
+	imgID, err := l.is.Create(config)
 	if err != nil {
-		logrus.Debugf("Error reading embedded tar: %v", err)
-		return nil, err
+		return err
 	}
-	defer rawTar.Close()
 
-	inflatedLayerData, err := archive.DecompressStream(rawTar)
+	for _, repoTag := range repoTags {
+		named, err := reference.ParseNamed(repoTag)
+		if err != nil {
+			return err
+		}
+		ref, ok := named.(reference.NamedTagged)
+		if !ok {
+			return fmt.Errorf("invalid tag %q", repoTag)
+		}
+		l.setLoadedTag(ref, imgID, outStream)
+		logrus.Debugf("Load() - %v(%v) has been loaded.", ref, imgID)
+		sf := streamformatter.NewJSONStreamFormatter()
+		outStream.Write(sf.FormatStatus("", "%v(%v) has been loaded.", ref, imgID))
+	}
+	return nil
+}
+
+func (l *tarexporter) loadLayer(filename string, rootFS image.RootFS, id string, foreignSrc distribution.Descriptor, progressOutput progress.Output) (layer.Layer, error) {
+	rawTar, err := os.Open(filename)
 	if err != nil {
+		logrus.Debugf("Error reading embedded tar: %v", err)
 		return nil, err
 	}
-	defer inflatedLayerData.Close()
+	defer rawTar.Close()
 
+	var r io.Reader
 	if progressOutput != nil {
 		fileInfo, err := os.Stat(filename)
 		if err != nil {
 			logrus.Debugf("Error statting file: %v", err)
 			return nil, err
 		}
-		progressReader := progress.NewProgressReader(inflatedLayerData, progressOutput, fileInfo.Size(), stringid.TruncateID(id), "Loading layer")
-		return l.ls.Register(progressReader, rootFS.ChainID())
+		r = progress.NewProgressReader(rawTar, progressOutput, fileInfo.Size(), stringid.TruncateID(id), "Loading layer")
+	} else {
+		r = rawTar
 	}
 
+	inflatedLayerData, err := archive.DecompressStream(r)
+	if err != nil {
+		return nil, err
+	}
+	defer inflatedLayerData.Close()
+
+	if ds, ok := l.ls.(layer.DescribableStore); ok && foreignSrc.Digest != "" {
+		return ds.RegisterWithDescriptor(inflatedLayerData, rootFS.ChainID(), foreignSrc)
+	}
 	return l.ls.Register(inflatedLayerData, rootFS.ChainID())
 }
 
@@ -180,7 +525,7 @@ func (l *tarexporter) setLoadedTag(ref reference.NamedTagged, imgID image.ID, ou
 	return nil
 }
 
-func (l *tarexporter) ociLoad(tmpDir, name string, refs map[string]string, outStream io.Writer, progressOutput progress.Output) error {
+func (l *tarexporter) ociLoad(tmpDir, name string, refs map[string]string, outStream io.Writer, progressOutput progress.Output, opts LoadOptions) error {
 	if name != "" && len(refs) != 0 {
 		return fmt.Errorf("cannot load with either name and refs")
 	}
@@ -189,7 +534,6 @@ func (l *tarexporter) ociLoad(tmpDir, name string, refs map[string]string, outSt
 		return fmt.Errorf("no OCI image name mapping provided")
 	}
 
-	var manifests []manifestItem
 	indexJSON, err := os.Open(filepath.Join(tmpDir, "index.json"))
 	if err != nil {
 		return err
@@ -199,29 +543,49 @@ func (l *tarexporter) ociLoad(tmpDir, name string, refs map[string]string, outSt
 	if err := json.NewDecoder(indexJSON).Decode(&index); err != nil {
 		return err
 	}
+
+	var matching []ociv1.Descriptor
 	for _, md := range index.Manifests {
 		if md.MediaType != ociv1.MediaTypeImageManifest {
 			continue
 		}
+		if opts.Platform != nil && !platformMatches(md.Platform, opts.Platform) {
+			continue
+		}
+		matching = append(matching, md)
+	}
+	if len(matching) == 0 {
+		return fmt.Errorf("no manifest found matching the requested platform")
+	}
+
+	sigsByManifest := collectManifestSignatures(index)
+
+	for _, md := range matching {
 		d := digest.Digest(md.Digest)
-		manifestPath := filepath.Join(tmpDir, "blobs", d.Algorithm().String(), d.Hex())
-		f, err := os.Open(manifestPath)
+		man := ociv1.Manifest{}
+		manBlob, err := readVerifiedBlob(tmpDir, d)
 		if err != nil {
+			return fmt.Errorf("error reading manifest blob %s: %v", stringid.TruncateID(d.String()), err)
+		}
+		if err := json.Unmarshal(manBlob, &man); err != nil {
 			return err
 		}
-		defer f.Close()
-		man := ociv1.Manifest{}
-		if err := json.NewDecoder(f).Decode(&man); err != nil {
+		configDigest := digest.Digest(man.Config.Digest)
+		config, err := readVerifiedBlob(tmpDir, configDigest)
+		if err != nil {
+			return fmt.Errorf("error reading config blob %s: %v", stringid.TruncateID(configDigest.String()), err)
+		}
+		img, err := image.NewFromJSON(config)
+		if err != nil {
 			return err
 		}
-		layers := make([]string, len(man.Layers))
-		for i, l := range man.Layers {
-			layerDigest := digest.Digest(l.Digest)
-			layers[i] = filepath.Join("blobs", layerDigest.Algorithm().String(), layerDigest.Hex())
+		if expected, actual := len(man.Layers), len(img.RootFS.DiffIDs); expected != actual {
+			return fmt.Errorf("invalid manifest, layers length mismatch: expected %q, got %q", expected, actual)
 		}
+
 		tag := ""
-		refName, ok := md.Annotations["org.opencontainers.ref.name"]
-		if !ok {
+		refName, hasRefName := md.Annotations["org.opencontainers.ref.name"]
+		if !hasRefName && !(name != "" && len(matching) == 1) {
 			return fmt.Errorf("no ref name annotation")
 		}
 		if name != "" {
@@ -229,11 +593,39 @@ func (l *tarexporter) ociLoad(tmpDir, name string, refs map[string]string, outSt
 			if err != nil {
 				return err
 			}
-			withTag, err := reference.WithTag(named, refName)
-			if err != nil {
-				return err
+			if !hasRefName {
+				// A single-manifest index with no ref name annotation: fall
+				// back to the user-supplied name, tagged "latest" if it
+				// carries no tag.
+				if tagged, ok := named.(reference.NamedTagged); ok {
+					tag = tagged.String()
+				} else {
+					withTag, err := reference.WithTag(named, "latest")
+					if err != nil {
+						return err
+					}
+					tag = withTag.String()
+				}
+			} else {
+				platTag := refName
+				if len(matching) > 1 {
+					suffix := platformSuffix(md.Platform)
+					if suffix == "" {
+						// Without a platform, this descriptor can't be
+						// disambiguated from any other manifest sharing
+						// refName in the same index -- tagging it as-is
+						// would silently overwrite whichever one registers
+						// first.
+						return fmt.Errorf("manifest %s has no platform to disambiguate it from other manifests named %q in this index", stringid.TruncateID(d.String()), refName)
+					}
+					platTag = refName + "-" + suffix
+				}
+				withTag, err := reference.WithTag(named, platTag)
+				if err != nil {
+					return err
+				}
+				tag = withTag.String()
 			}
-			tag = withTag.String()
 		} else {
 			_, rs, err := getRefs(refs)
 			if err != nil {
@@ -245,17 +637,144 @@ func (l *tarexporter) ociLoad(tmpDir, name string, refs map[string]string, outSt
 			}
 			tag = r.String()
 		}
-		configDigest := digest.Digest(man.Config.Digest)
-		manifests = append(manifests, manifestItem{
-			Config:   filepath.Join("blobs", configDigest.Algorithm().String(), configDigest.Hex()),
-			RepoTags: []string{tag},
-			Layers:   layers,
-			// TODO(runcom): foreign srcs?
-			// See https://github.com/docker/docker/pull/22866/files#r96125181
+
+		namedRef, err := reference.ParseNamed(tag)
+		if err != nil {
+			return err
+		}
+		if err := opts.Verifier.VerifyManifest(namedRef, d, sigsByManifest[d]); err != nil {
+			return fmt.Errorf("signature verification failed for %s: %v", tag, err)
+		}
+
+		rootFS := *img.RootFS
+		rootFS.DiffIDs = nil
+		for i, desc := range man.Layers {
+			diffID := img.RootFS.DiffIDs[i]
+			r := rootFS
+			r.Append(diffID)
+			newLayer, err := l.ls.Get(r.ChainID())
+			if err != nil {
+				newLayer, err = l.registerOCILayer(tmpDir, desc, rootFS, progressOutput)
+				if err != nil {
+					return err
+				}
+			}
+			defer layer.ReleaseAndLog(l.ls, newLayer)
+			if expected, actual := diffID, newLayer.DiffID(); expected != actual {
+				return fmt.Errorf("invalid diffID for layer %d: expected %q, got %q", i, expected, actual)
+			}
+			rootFS.Append(diffID)
+		}
+
+		if err := l.registerImage(config, []string{tag}, outStream); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// platformMatches reports whether a manifest descriptor's platform satisfies
+// the requested platform filter. A descriptor with no platform never
+// matches an explicit filter.
+func platformMatches(p, want *ociv1.Platform) bool {
+	if p == nil {
+		return false
+	}
+	if p.OS != want.OS || p.Architecture != want.Architecture {
+		return false
+	}
+	if want.Variant != "" && p.Variant != want.Variant {
+		return false
+	}
+	return true
+}
+
+// platformSuffix renders a platform as the "os-arch[-variant]" suffix used
+// to disambiguate tags generated from a multi-platform index.
+func platformSuffix(p *ociv1.Platform) string {
+	if p == nil {
+		return ""
+	}
+	suffix := p.OS + "-" + p.Architecture
+	if p.Variant != "" {
+		suffix += "-" + p.Variant
+	}
+	return suffix
+}
+
+// readVerifiedBlob reads the OCI content-addressable blob named by d and
+// verifies its contents hash to d before returning them, so a corrupt blob
+// is rejected immediately instead of surfacing later as a diffID mismatch.
+func readVerifiedBlob(tmpDir string, d digest.Digest) ([]byte, error) {
+	f, err := os.Open(filepath.Join(tmpDir, "blobs", d.Algorithm().String(), d.Hex()))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	verifier := d.Verifier()
+	content, err := ioutil.ReadAll(io.TeeReader(f, verifier))
+	if err != nil {
+		return nil, err
+	}
+	if !verifier.Verified() {
+		return nil, fmt.Errorf("digest verification failed for blob %s", stringid.TruncateID(d.String()))
+	}
+	return content, nil
+}
+
+// registerOCILayer streams and registers the layer blob described by desc,
+// hashing the blob as it is read; if the digest does not match once the
+// layer is registered, the new layer is released and an error returned.
+// Its caller already checks l.ls.Get for the chain ID of rootFS with this
+// layer's diffID appended before calling this, so the one case worth
+// caching across manifests -- an
+// identical ancestor chain shared by two manifests in the same index -- is
+// already handled; a cache keyed on desc.Digest alone would risk handing
+// back a layer registered under an unrelated parent chain.
+func (l *tarexporter) registerOCILayer(tmpDir string, desc ociv1.Descriptor, rootFS image.RootFS, progressOutput progress.Output) (layer.Layer, error) {
+	d := digest.Digest(desc.Digest)
+
+	blob, err := os.Open(filepath.Join(tmpDir, "blobs", d.Algorithm().String(), d.Hex()))
+	if err != nil {
+		return nil, err
+	}
+	defer blob.Close()
+
+	verifier := d.Verifier()
+	var r io.Reader = io.TeeReader(blob, verifier)
+	if progressOutput != nil {
+		r = progress.NewProgressReader(r, progressOutput, desc.Size, stringid.TruncateID(d.String()), "Loading layer")
+	}
+
+	inflated, err := archive.DecompressStream(r)
+	if err != nil {
+		return nil, err
+	}
+	defer inflated.Close()
+
+	var newLayer layer.Layer
+	if ds, ok := l.ls.(layer.DescribableStore); ok && nondistributableMediaType(desc.MediaType) && len(desc.URLs) > 0 {
+		newLayer, err = ds.RegisterWithDescriptor(inflated, rootFS.ChainID(), distribution.Descriptor{
+			MediaType: desc.MediaType,
+			Digest:    d,
+			Size:      desc.Size,
+			URLs:      desc.URLs,
 		})
+	} else {
+		newLayer, err = l.ls.Register(inflated, rootFS.ChainID())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !verifier.Verified() {
+		layer.ReleaseAndLog(l.ls, newLayer)
+		return nil, fmt.Errorf("digest verification failed for blob %s", stringid.TruncateID(d.String()))
 	}
 
-	return l.loadHelper(tmpDir, manifests, outStream, progressOutput)
+	return newLayer, nil
 }
 
 func (l *tarexporter) legacyLoad(tmpDir string, outStream io.Writer, progressOutput progress.Output) error {
@@ -367,7 +886,7 @@ func (l *tarexporter) legacyLoadImage(oldID, sourceDir string, loadedMap map[str
 	if err != nil {
 		return err
 	}
-	newLayer, err := l.loadLayer(layerPath, *rootFS, oldID, progressOutput)
+	newLayer, err := l.loadLayer(layerPath, *rootFS, oldID, distribution.Descriptor{}, progressOutput)
 	if err != nil {
 		return err
 	}
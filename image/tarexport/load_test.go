@@ -0,0 +1,168 @@
+package tarexport
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	digest "github.com/opencontainers/go-digest"
+	ociv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// The rest of this package (tarexporter, manifestItem, getRefs, ...)
+// lives in sibling files not present in this snapshot, so these tests
+// are limited to the self-contained helpers below.
+
+func TestReadVerifiedBlobRejectsCorruptContent(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "tarexport-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	d := digest.FromBytes([]byte("expected content"))
+	blobDir := filepath.Join(tmpDir, "blobs", d.Algorithm().String())
+	if err := os.MkdirAll(blobDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(blobDir, d.Hex()), []byte("corrupted content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := readVerifiedBlob(tmpDir, d); err == nil {
+		t.Fatal("expected an error for a blob whose content doesn't match its declared digest")
+	}
+}
+
+func TestReadVerifiedBlobAcceptsMatchingContent(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "tarexport-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	content := []byte("expected content")
+	d := digest.FromBytes(content)
+	blobDir := filepath.Join(tmpDir, "blobs", d.Algorithm().String())
+	if err := os.MkdirAll(blobDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(blobDir, d.Hex()), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := readVerifiedBlob(tmpDir, d)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(got) != string(content) {
+		t.Fatalf("got %q, want %q", got, content)
+	}
+}
+
+func TestCollectManifestSignaturesKeyedByReferenceDigest(t *testing.T) {
+	imageDigest := digest.FromBytes([]byte("image manifest"))
+	otherDigest := digest.FromBytes([]byte("other image manifest"))
+
+	index := ociv1.ImageIndex{
+		Manifests: []ociv1.Descriptor{
+			{MediaType: ociv1.MediaTypeImageManifest, Digest: imageDigest},
+			{
+				MediaType:   mediaTypeCosignSimpleSigning,
+				Digest:      digest.FromBytes([]byte("sig")),
+				Annotations: map[string]string{"vnd.docker.reference.digest": imageDigest.String()},
+			},
+			{
+				MediaType:   mediaTypeInTotoAttestation,
+				Digest:      digest.FromBytes([]byte("attestation")),
+				Annotations: map[string]string{"vnd.docker.reference.digest": otherDigest.String()},
+			},
+		},
+	}
+
+	sigs := collectManifestSignatures(index)
+	if len(sigs[imageDigest]) != 1 {
+		t.Fatalf("expected 1 signature bound to %s, got %d", imageDigest, len(sigs[imageDigest]))
+	}
+	if len(sigs[otherDigest]) != 1 {
+		t.Fatalf("expected 1 signature bound to %s, got %d", otherDigest, len(sigs[otherDigest]))
+	}
+}
+
+// TestCollectDirectorySignaturesHasNoPerImageBinding documents why
+// loadHelper/loadHelperStream only honor a directory-sourced signature list
+// when the archive has exactly one manifest: collectDirectorySignatures
+// itself has no digest to key by, so it always returns every file found
+// under signatures/ regardless of how many images share the archive. The
+// one-manifest guard that keeps this from conflating unrelated images lives
+// in loadHelper/loadHelperStream, which need the tarexporter/ImageStore
+// plumbing this snapshot doesn't contain, so it isn't re-asserted here.
+func TestCollectDirectorySignaturesHasNoPerImageBinding(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "tarexport-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if sigs := collectDirectorySignatures(tmpDir); sigs != nil {
+		t.Fatalf("expected no signatures without a signatures/ directory, got %v", sigs)
+	}
+
+	sigDir := filepath.Join(tmpDir, "signatures")
+	if err := os.MkdirAll(sigDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(sigDir, "sig1.json"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(sigDir, "sig2.json"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sigs := collectDirectorySignatures(tmpDir)
+	if len(sigs) != 2 {
+		t.Fatalf("expected every file under signatures/ regardless of image count, got %d", len(sigs))
+	}
+}
+
+func TestPlatformMatches(t *testing.T) {
+	want := &ociv1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}
+
+	cases := []struct {
+		name string
+		p    *ociv1.Platform
+		want bool
+	}{
+		{"nil platform never matches", nil, false},
+		{"os/arch mismatch", &ociv1.Platform{OS: "windows", Architecture: "arm", Variant: "v7"}, false},
+		{"variant mismatch", &ociv1.Platform{OS: "linux", Architecture: "arm", Variant: "v6"}, false},
+		{"exact match", &ociv1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := platformMatches(c.p, want); got != c.want {
+				t.Errorf("platformMatches(%+v, %+v) = %v, want %v", c.p, want, got, c.want)
+			}
+		})
+	}
+}
+
+func TestPlatformSuffix(t *testing.T) {
+	cases := []struct {
+		name string
+		p    *ociv1.Platform
+		want string
+	}{
+		{"nil platform has no suffix", nil, ""},
+		{"os/arch only", &ociv1.Platform{OS: "linux", Architecture: "amd64"}, "linux-amd64"},
+		{"with variant", &ociv1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}, "linux-arm-v7"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := platformSuffix(c.p); got != c.want {
+				t.Errorf("platformSuffix(%+v) = %q, want %q", c.p, got, c.want)
+			}
+		})
+	}
+}